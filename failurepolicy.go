@@ -0,0 +1,103 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const (
+	// failurePolicyDrop removes an endpoint from the generated hashring as
+	// soon as its readiness probe fails. This is the historical behavior.
+	failurePolicyDrop = "drop"
+	// failurePolicyKeepLastKnown reuses the previously generated endpoint
+	// entry for a hashring when its probe now fails, instead of dropping it.
+	failurePolicyKeepLastKnown = "keep-last-known"
+	// failurePolicyQuorumPrefix, followed by a percentage (e.g. "quorum:80%"),
+	// only regenerates a hashring's endpoints when at least that percentage
+	// of its endpoints are healthy.
+	failurePolicyQuorumPrefix = "quorum:"
+)
+
+// failurePolicy describes how a hashring's generated endpoint list should be
+// reconciled with the outcome of the readiness probes.
+type failurePolicy struct {
+	mode          string
+	quorumPercent int
+}
+
+// parseFailurePolicy parses the --endpoint-failure-policy flag value.
+func parseFailurePolicy(value string) (failurePolicy, error) {
+	switch {
+	case value == failurePolicyDrop || value == "":
+		return failurePolicy{mode: failurePolicyDrop}, nil
+	case value == failurePolicyKeepLastKnown:
+		return failurePolicy{mode: failurePolicyKeepLastKnown}, nil
+	case strings.HasPrefix(value, failurePolicyQuorumPrefix):
+		percentStr := strings.TrimSuffix(strings.TrimPrefix(value, failurePolicyQuorumPrefix), "%")
+		percent, err := strconv.Atoi(percentStr)
+		if err != nil || percent < 0 || percent > 100 {
+			return failurePolicy{}, fmt.Errorf("invalid quorum percentage in %q, expected e.g. %q", value, "quorum:80%")
+		}
+		return failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: percent}, nil
+	default:
+		return failurePolicy{}, fmt.Errorf("unknown endpoint failure policy %q, expected %q, %q or %q", value, failurePolicyDrop, failurePolicyKeepLastKnown, failurePolicyQuorumPrefix+"N%")
+	}
+}
+
+// reconcile applies the failure policy to a single hashring's probe results,
+// given the endpoints that were healthy this round, the endpoints that were
+// present in the previously generated hashring of the same name, and the
+// number of endpoints currently configured for this hashring (used as the
+// quorum denominator, since `previous` reflects the last successful
+// generation rather than this round's input). It returns the endpoints to
+// write out and whether the hashring should be regenerated at all (false
+// only happens under the quorum policy).
+func (p failurePolicy) reconcile(logger kitlog.Logger, hashringName string, healthy, previous []string, configured int) ([]string, bool) {
+	switch p.mode {
+	case failurePolicyKeepLastKnown:
+		healthySet := make(map[string]bool, len(healthy))
+		for _, endpoint := range healthy {
+			healthySet[endpoint] = true
+		}
+		endpoints := append([]string{}, healthy...)
+		for _, endpoint := range previous {
+			if !healthySet[endpoint] {
+				endpoints = append(endpoints, endpoint)
+				healthySet[endpoint] = true
+			}
+		}
+		return endpoints, true
+	case failurePolicyQuorumPrefix:
+		if configured == 0 {
+			return healthy, true
+		}
+		healthyPercent := len(healthy) * 100 / configured
+		if healthyPercent < p.quorumPercent {
+			level.Error(logger).Log("msg", "hashring has too few healthy endpoints, keeping previous generated endpoints", "hashring", hashringName, "healthy_percent", healthyPercent, "quorum_percent", p.quorumPercent)
+			return nil, false
+		}
+		return healthy, true
+	default:
+		return healthy, true
+	}
+}
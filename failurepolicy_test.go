@@ -0,0 +1,129 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    failurePolicy
+		wantErr bool
+	}{
+		{name: "empty defaults to drop", value: "", want: failurePolicy{mode: failurePolicyDrop}},
+		{name: "drop", value: "drop", want: failurePolicy{mode: failurePolicyDrop}},
+		{name: "keep-last-known", value: "keep-last-known", want: failurePolicy{mode: failurePolicyKeepLastKnown}},
+		{name: "quorum", value: "quorum:80%", want: failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}},
+		{name: "quorum 0%", value: "quorum:0%", want: failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 0}},
+		{name: "quorum 100%", value: "quorum:100%", want: failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 100}},
+		{name: "quorum missing percent sign still parses the number", value: "quorum:80", want: failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}},
+		{name: "quorum out of range", value: "quorum:150%", wantErr: true},
+		{name: "quorum negative", value: "quorum:-10%", wantErr: true},
+		{name: "quorum not a number", value: "quorum:abc%", wantErr: true},
+		{name: "unknown policy", value: "bogus", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFailurePolicy(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseFailurePolicy(%q) = %+v, want an error", tc.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFailurePolicy(%q) returned unexpected error: %v", tc.value, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("parseFailurePolicy(%q) = %+v, want %+v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFailurePolicyReconcile(t *testing.T) {
+	logger := kitlog.NewNopLogger()
+
+	t.Run("drop keeps only the healthy endpoints", func(t *testing.T) {
+		p := failurePolicy{mode: failurePolicyDrop}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a"}, []string{"a", "b"}, 2)
+		if !regenerate || !reflect.DeepEqual(got, []string{"a"}) {
+			t.Fatalf("got %v, %v", got, regenerate)
+		}
+	})
+
+	t.Run("keep-last-known re-adds previous endpoints that aren't healthy", func(t *testing.T) {
+		p := failurePolicy{mode: failurePolicyKeepLastKnown}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a"}, []string{"a", "b"}, 2)
+		if !regenerate || !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Fatalf("got %v, %v", got, regenerate)
+		}
+	})
+
+	t.Run("keep-last-known does not duplicate endpoints already healthy", func(t *testing.T) {
+		p := failurePolicy{mode: failurePolicyKeepLastKnown}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a", "b"}, []string{"a", "b"}, 2)
+		if !regenerate || !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Fatalf("got %v, %v", got, regenerate)
+		}
+	})
+
+	t.Run("quorum denominator is the configured endpoint count, not previous or healthy", func(t *testing.T) {
+		// 3 out of 10 configured endpoints are healthy on a cold start
+		// (no previous generation yet): 30% < 80% quorum must fail, even
+		// though len(previous) is 0 and len(healthy) alone would read 100%.
+		p := failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a", "b", "c"}, nil, 10)
+		if regenerate || got != nil {
+			t.Fatalf("got %v, %v, want nil, false", got, regenerate)
+		}
+	})
+
+	t.Run("quorum passes when enough of the configured endpoints are healthy", func(t *testing.T) {
+		p := failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a", "b", "c", "d"}, []string{"a", "b", "c", "d"}, 4)
+		if !regenerate || !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+			t.Fatalf("got %v, %v", got, regenerate)
+		}
+	})
+
+	t.Run("quorum with zero configured endpoints regenerates instead of dividing by zero", func(t *testing.T) {
+		p := failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}
+		got, regenerate := p.reconcile(logger, "hashring", nil, nil, 0)
+		if !regenerate || len(got) != 0 {
+			t.Fatalf("got %v, %v, want empty, true", got, regenerate)
+		}
+	})
+
+	t.Run("quorum denominator tracks endpoint count changes across runs (scale up)", func(t *testing.T) {
+		// Previously generated with 2 endpoints, now scaled up to 8 with
+		// only 5 healthy: 62% < 80% quorum must fail even though 5/2
+		// (against the stale "previous" count) would read above quorum.
+		p := failurePolicy{mode: failurePolicyQuorumPrefix, quorumPercent: 80}
+		got, regenerate := p.reconcile(logger, "hashring", []string{"a", "b", "c", "d", "e"}, []string{"a", "b"}, 8)
+		if regenerate || got != nil {
+			t.Fatalf("got %v, %v, want nil, false", got, regenerate)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// rootLogger is the base structured logger every other logger in the
+// controller is derived from via kitlog.With. It's configured once in
+// main() from --log-level and --log-format.
+var rootLogger kitlog.Logger
+
+// newLogger builds a leveled, formatted logger matching the conventions of
+// the rest of the Thanos ecosystem: logfmt or JSON output, filtered by a
+// minimum level, timestamped and with the call site attached.
+func newLogger(format, lvl string) (kitlog.Logger, error) {
+	var logger kitlog.Logger
+	switch format {
+	case "", "logfmt":
+		logger = kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(os.Stdout))
+	case "json":
+		logger = kitlog.NewJSONLogger(kitlog.NewSyncWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected \"logfmt\" or \"json\"", format)
+	}
+
+	var allow level.Option
+	switch lvl {
+	case "debug":
+		allow = level.AllowDebug()
+	case "", "info":
+		allow = level.AllowInfo()
+	case "warn":
+		allow = level.AllowWarn()
+	case "error":
+		allow = level.AllowError()
+	default:
+		return nil, fmt.Errorf("unknown log level %q, expected \"debug\", \"info\", \"warn\" or \"error\"", lvl)
+	}
+
+	logger = level.NewFilter(logger, allow)
+	logger = kitlog.With(logger, "ts", kitlog.DefaultTimestampUTC, "caller", kitlog.DefaultCaller)
+	return logger, nil
+}
@@ -26,7 +26,6 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"os/user"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -34,15 +33,16 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 )
 
 const AppVersion = "0.0.1"
 
-var (
-	DebugLogger *log.Logger
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
-)
+// errSampler rate-limits the repetitive "endpoint not ready" error line so a
+// receiver that's down for hours doesn't spam the log every interval.
+var errSampler = newLogSampler(0)
 
 // HashringConfig represents the configuration for a hashring
 // a receive node knows about.
@@ -57,85 +57,87 @@ func httpClient(timeout int) *http.Client {
 	return client
 }
 
-func saveHashringFile(file, owner string, content []byte) {
-	g, err := user.Lookup(owner)
-	if err != nil {
-		ErrorLogger.Printf("Cannot save file %s, error: %+v", file, err)
-		return
-	}
-
-	err = ioutil.WriteFile(file, content, 0644)
-	if err != nil {
-		ErrorLogger.Printf("Cannot save file %s, error %+v", file, err)
-		return
-	}
-
-	uid, _ := strconv.Atoi(g.Uid)
-	gid, _ := strconv.Atoi(g.Gid)
-	err = os.Chown(file, uid, gid)
-	if err != nil {
-		ErrorLogger.Printf("Cannot set %s owner on file %s. %+v", owner, file, err)
-		return
-	}
-	InfoLogger.Printf("File %s saved.", file)
-}
-
-func healthyEndpoint(ch chan string, wg *sync.WaitGroup, scheme, endpoint string, timeout, portOffset int, verbose bool) {
-	defer wg.Done()
+// probeOnce performs a single readiness GET request against an endpoint.
+func probeOnce(scheme, endpoint string, timeout, portOffset int) (string, error) {
 	endpointSplit := strings.Split(endpoint, ":")
 	host := endpointSplit[0]
 	port, _ := strconv.Atoi(endpointSplit[1])
 	url := fmt.Sprintf("%s://%s:%d/-/ready", scheme, host, port+portOffset)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		ErrorLogger.Printf("Error Occurred. %+v", err)
-		return
+		return url, err
 	}
 
 	response, err := httpClient(timeout).Do(req)
 	if err != nil {
-		ErrorLogger.Printf("Error sending request to endpoint: %+v", err)
-		return
+		return url, err
 	}
+	defer response.Body.Close()
 
 	bodyBytes, _ := ioutil.ReadAll(response.Body)
-	// Close the connection to reuse it
-	defer response.Body.Close()
-	if response.StatusCode == 200 && string(bodyBytes) == "OK" {
-		if verbose {
-			DebugLogger.Printf("Endpoint %s is ready.", endpoint)
-		}
-	} else {
-		ErrorLogger.Printf("Endpoint is not ready: Getting %d from %s: %s", response.StatusCode, url, string(bodyBytes))
-		return
+	if response.StatusCode != 200 || string(bodyBytes) != "OK" {
+		return url, fmt.Errorf("getting %d from %s: %s", response.StatusCode, url, string(bodyBytes))
 	}
-	ch <- endpoint
+	return url, nil
 }
 
-func checkHashringFile(file, owner string, scheme string, endpointTimeout, portOffset int, wg *sync.WaitGroup, verbose bool) {
+// healthyEndpoint probes an endpoint's readiness, retrying with exponential
+// backoff and jitter up to retry.Retries times, and sends it on ch only if
+// it ends up healthy.
+func healthyEndpoint(ch chan string, wg *sync.WaitGroup, scheme, endpoint string, timeout, portOffset int, retry retryConfig, logger kitlog.Logger) {
 	defer wg.Done()
-	// Read trusted source file to perform healthy request on expected endpoints
-	body, err := ioutil.ReadFile(file)
-	if err != nil {
-		ErrorLogger.Printf("Unable to read file %s: %v", file, err)
-		return
+	logger = kitlog.With(logger, "endpoint", endpoint)
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		url, err := probeOnce(scheme, endpoint, timeout, portOffset)
+		if err == nil {
+			level.Debug(logger).Log("msg", "endpoint is ready")
+			probeDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+			ch <- endpoint
+			return
+		}
+		probeErrorsTotal.WithLabelValues(endpoint).Inc()
+
+		if attempt >= retry.Retries {
+			if allow, suppressed := errSampler.allow(endpoint); allow {
+				level.Error(logger).Log("msg", "endpoint is not ready", "attempts", attempt+1, "err", err, "suppressed", suppressed)
+			}
+			probeDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+			return
+		}
+
+		wait := backoffDuration(attempt, retry.Backoff, retry.MaxBackoff)
+		level.Debug(logger).Log("msg", "endpoint not ready, retrying", "err", err, "url", url, "wait", wait)
+		time.Sleep(wait)
 	}
+}
 
+// processHashring decodes the raw hashring JSON content, probes every
+// endpoint of every hashring for readiness and applies the configured
+// failure policy against the previously generated hashrings (keyed by
+// hashring name) to decide the final endpoint list. It returns the
+// resulting hashrings, the subset of hashring names the failure policy
+// decided NOT to regenerate (e.g. a quorum that wasn't met), and the raw
+// per-endpoint probe outcomes for observability.
+func processHashring(body []byte, scheme string, endpointTimeout, portOffset int, retry retryConfig, policy failurePolicy, previous map[string]HashringConfig, logger kitlog.Logger) ([]HashringConfig, map[string]bool, map[string][]EndpointProbe, error) {
 	// Decode json hashring format into struct
 	var hashrings []HashringConfig
-	if err := json.Unmarshal([]byte(body), &hashrings); err != nil {
-		ErrorLogger.Printf("Unable to json decode file %s: %v", file, err)
-		return
+	if err := json.Unmarshal(body, &hashrings); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to json decode content: %w", err)
 	}
 
+	skipped := make(map[string]bool)
+	probes := make(map[string][]EndpointProbe)
+
 	// Set soncurrency http request for each endpoint
 	for pos, hashring := range hashrings {
+		hashringLogger := kitlog.With(logger, "hashring", hashring.Hashring)
 		var wgEndpoint sync.WaitGroup
-		var endpoints []string
+		var healthy []string
 		queue := make(chan string, len(hashring.Endpoints))
 		for _, endpoint := range hashring.Endpoints {
 			wgEndpoint.Add(1)
-			go healthyEndpoint(queue, &wgEndpoint, scheme, endpoint, endpointTimeout, portOffset, verbose)
+			go healthyEndpoint(queue, &wgEndpoint, scheme, endpoint, endpointTimeout, portOffset, retry, hashringLogger)
 		}
 
 		go func() {
@@ -144,54 +146,110 @@ func checkHashringFile(file, owner string, scheme string, endpointTimeout, portO
 		}()
 
 		for result := range queue {
-			endpoints = append(endpoints, result)
+			healthy = append(healthy, result)
+		}
+
+		healthySet := make(map[string]bool, len(healthy))
+		for _, endpoint := range healthy {
+			healthySet[endpoint] = true
 		}
+		for _, endpoint := range hashring.Endpoints {
+			probes[hashring.Hashring] = append(probes[hashring.Hashring], EndpointProbe{Endpoint: endpoint, Healthy: healthySet[endpoint]})
+		}
+		hashringEndpoints.WithLabelValues(hashring.Hashring, "healthy").Set(float64(len(healthy)))
+		hashringEndpoints.WithLabelValues(hashring.Hashring, "unhealthy").Set(float64(len(hashring.Endpoints) - len(healthy)))
+
+		endpoints, regenerate := policy.reconcile(hashringLogger, hashring.Hashring, healthy, previous[hashring.Hashring].Endpoints, len(hashring.Endpoints))
+		if !regenerate {
+			skipped[hashring.Hashring] = true
+			endpoints = previous[hashring.Hashring].Endpoints
+		}
+
 		// Sort endpoints list to avoid diff changes when comparing sha256sum
 		sort.Strings(endpoints)
 		hashrings[pos].Endpoints = endpoints
 	}
 
-	//Encode content struct to json hashring format
-	content, err := json.Marshal(hashrings)
+	return hashrings, skipped, probes, nil
+}
+
+func checkHashringFile(source HashringSource, owner, scheme string, endpointTimeout, portOffset int, retry retryConfig, policy failurePolicy, output string, extraSinks []HashringSink, state *controllerState, wg *sync.WaitGroup, logger kitlog.Logger) {
+	defer wg.Done()
+	logger = kitlog.With(logger, "source", source.Name())
+	// Read trusted source to perform healthy request on expected endpoints
+	body, err := source.Load()
 	if err != nil {
-		ErrorLogger.Printf("Error Occurred. %+v", err)
+		level.Error(logger).Log("msg", "unable to load hashring source", "err", err)
 		return
 	}
 
-	// Get sha256 checksum from content
-	contentSha256Sum := sha256.Sum256([]byte(content))
+	// Derive the generated file path from the source
+	generatedFile := generatedFilePath(source, output)
+	if generatedFile == "" {
+		level.Error(logger).Log("msg", "no output file configured for source, set '--output'")
+		return
+	}
+	logger = kitlog.With(logger, "file", generatedFile)
+
+	// The local file sink doubles as the controller's own bookkeeping: its
+	// current content is what the failure policy falls back to.
+	sinks := append([]HashringSink{&FileSink{Path: generatedFile, Owner: owner, Logger: logger}}, extraSinks...)
+
+	previous := make(map[string]HashringConfig)
+	if existing, ok := sinks[0].Current(); ok {
+		var previousHashrings []HashringConfig
+		if err := json.Unmarshal(existing, &previousHashrings); err == nil {
+			for _, hashring := range previousHashrings {
+				previous[hashring.Hashring] = hashring
+			}
+		}
+	}
 
-	// Create new filename for the generated file from trusted source filename
-	generatedFile := fmt.Sprintf("%s_generated.json", strings.Split(file, ".json")[0])
+	hashrings, skipped, probes, err := processHashring(body, scheme, endpointTimeout, portOffset, retry, policy, previous, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to process hashring source", "err", err)
+		return
+	}
 
-	save := true
-	// Check if generated file already exists
-	if _, err := os.Stat(generatedFile); err == nil {
-		body, err := ioutil.ReadFile(generatedFile)
-		if err != nil {
-			ErrorLogger.Printf("Unable to read file %s: %v", file, err)
-			return
-		}
+	if len(skipped) == len(hashrings) && len(hashrings) > 0 {
+		// Every hashring failed its failure policy, nothing to regenerate.
+		return
+	}
 
-		// Get sha256 checksum from generated file content
-		gFileSha256Sum := sha256.Sum256([]byte(body))
+	//Encode content struct to json hashring format
+	content, err := json.Marshal(hashrings)
+	if err != nil {
+		level.Error(logger).Log("msg", "error encoding hashring content", "err", err)
+		return
+	}
+	contentSha256Sum := sha256.Sum256(content)
 
-		// Check if current content is different than existing generated file content
-		if string(gFileSha256Sum[:]) == string(contentSha256Sum[:]) {
-			save = false
-			if verbose {
-				DebugLogger.Printf("Hashring file %s is OK, no update needed", generatedFile)
-			}
+	for _, sink := range sinks {
+		changed, err := writeIfChanged(sink, content)
+		if err != nil {
+			level.Error(logger).Log("msg", "error writing to sink", "sink", sink.Name(), "err", err)
+			continue
+		}
+		if changed {
+			configChangesTotal.WithLabelValues(sink.Name()).Inc()
+		} else {
+			level.Debug(logger).Log("msg", "sink is OK, no update needed", "sink", sink.Name())
 		}
 	}
 
-	// Save/Overwrite generated file content
-	if save {
-		saveHashringFile(generatedFile, owner, content)
+	if state != nil {
+		state.update(SourceStatus{
+			Source:        source.Name(),
+			GeneratedFile: generatedFile,
+			Content:       string(content),
+			Sha256:        fmt.Sprintf("%x", contentSha256Sum),
+			LastRun:       time.Now(),
+			Probes:        probes,
+		})
 	}
 }
 
-func listHashringFiles(directory string) []string {
+func listHashringFiles(directory string, logger kitlog.Logger) []string {
 	var files []string
 	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -204,48 +262,60 @@ func listHashringFiles(directory string) []string {
 		return nil
 	})
 	if err != nil {
-		ErrorLogger.Println(err)
+		level.Error(logger).Log("msg", "unable to list hashring files", "directory", directory, "err", err)
 	}
 	return files
 }
 
-func run(files []string, owner, scheme string, timeout, portOffset int, verbose bool) {
-	// Set concurrency watcher for each hashring file
+func run(sources []HashringSource, owner, scheme string, timeout, portOffset int, retry retryConfig, policy failurePolicy, output string, extraSinks []HashringSink, state *controllerState, logger kitlog.Logger) {
+	// Set concurrency watcher for each hashring source
 	var wg sync.WaitGroup
-	for _, file := range files {
+	for _, source := range sources {
 		wg.Add(1)
-		go checkHashringFile(file, owner, scheme, timeout, portOffset, &wg, verbose)
+		go checkHashringFile(source, owner, scheme, timeout, portOffset, retry, policy, output, extraSinks, state, &wg, logger)
 	}
 	wg.Wait()
-}
 
-func buildFilesList(directory, file string, verbose bool) []string {
-	var hashringFiles []string
-	if file != "" {
-		hashringFiles = append(hashringFiles, file)
-	} else {
-		hashringFiles = listHashringFiles(directory)
-	}
-	if verbose {
-		DebugLogger.Printf("Watching files: %v", hashringFiles)
+	if state != nil {
+		now := time.Now()
+		state.setLastReconcile(now)
+		lastReconcileTimestamp.Set(float64(now.Unix()))
 	}
-	return hashringFiles
 }
 
 func main() {
-	InfoLogger = log.New(os.Stdout, "INFO ", log.Ldate|log.Ltime|log.Lshortfile)
-	DebugLogger = log.New(os.Stdout, "DEBUG ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(os.Stderr, "ERROR ", log.Ldate|log.Ltime|log.Lshortfile)
-
-	file := flag.String("file", "", "Hashring filepath to watch. (mutually exclusive with '--directory')")
-	directory := flag.String("directory", "", "Directory path to watch hashring files. (mutually exclusive with '--file')")
+	file := flag.String("file", "", "Hashring filepath to watch. (mutually exclusive with '--directory', '--hashring-content' and '--hashring-url')")
+	directory := flag.String("directory", "", "Directory path to watch hashring files. (mutually exclusive with '--file', '--hashring-content' and '--hashring-url')")
+	hashringContent := flag.String("hashring-content", "", "Raw hashring JSON content. (mutually exclusive with '--file', '--directory' and '--hashring-url')")
+	hashringURL := flag.String("hashring-url", "", "HTTP(S) endpoint returning the hashring JSON content. (mutually exclusive with '--file', '--directory' and '--hashring-content')")
+	hashringURLHeaders := make(headerFlag)
+	flag.Var(hashringURLHeaders, "hashring-url-header", "Additional \"Key: Value\" header sent with '--hashring-url' requests, e.g. for authentication. (can be repeated)")
+	output := flag.String("output", "", "Generated hashring filepath. (required when using '--hashring-content' or '--hashring-url')")
 	owner := flag.String("owner", "thanos", "Set owner on generated hashring files.")
 	endpointScheme := flag.String("endpoint-scheme", "http", "Endpoint scheme to perform readiness requests.")
 	endpointTimeout := flag.Int("endpoint-timeout", 5, "Endpoint timeout to perform readiness requests.")
 	endpointPortOffset := flag.Int("endpoint-port-offset", 1, "Endpoint port offset to perform readiness requests.")
+	endpointRetries := flag.Int("endpoint-retries", 0, "Number of retries for a failing endpoint readiness request.")
+	endpointRetryBackoff := flag.Duration("endpoint-retry-backoff", time.Second, "Base backoff duration between endpoint readiness retries.")
+	endpointRetryMaxBackoff := flag.Duration("endpoint-retry-max-backoff", 30*time.Second, "Maximum backoff duration between endpoint readiness retries.")
+	endpointFailurePolicy := flag.String("endpoint-failure-policy", failurePolicyDrop, "How to handle a hashring whose endpoints fail readiness: 'drop', 'keep-last-known' or 'quorum:N%'.")
+	listenAddress := flag.String("listen-address", ":10902", "Address to bind the HTTP server exposing /metrics, /-/healthy, /-/ready and the /api/v1 admin API.")
+	var sinkNames sinkNamesFlag
+	flag.Var(&sinkNames, "sink", "Additional sink to write the generated hashring JSON to, beyond the local file. (can be repeated: 'configmap', 'http'). Only supported with a single hashring source ('--file', '--hashring-content' or '--hashring-url'), since every source sharing a sink would clobber each other's content; not supported with '--directory'.")
+	configmapNamespace := flag.String("configmap-namespace", "", "Namespace of the ConfigMap to write to. (required for '--sink=configmap')")
+	configmapName := flag.String("configmap-name", "", "Name of the ConfigMap to write to. (required for '--sink=configmap')")
+	configmapKey := flag.String("configmap-key", "hashrings.json", "Key within the ConfigMap holding the generated hashring JSON.")
+	kubeconfig := flag.String("kubeconfig", "", "Path to a kubeconfig file, used only when in-cluster credentials are not available.")
+	sinkHTTPURL := flag.String("sink-http-url", "", "URL to PUT the generated hashring JSON to. (required for '--sink=http')")
+	sinkHTTPToken := flag.String("sink-http-token", "", "Optional bearer token sent with '--sink-http-url' requests.")
 	interval := flag.Int("interval", 10, "Watcher Scheduler interval in seconds.")
-	schedule := flag.Bool("schedule", false, "Enable hashring files watcher scheduler.")
-	verbose := flag.Bool("verbose", false, "Enabled verbose mode.")
+	schedule := flag.Bool("schedule", false, "Enable hashring files watcher scheduler. (mutually exclusive with '--watch')")
+	watch := flag.Bool("watch", false, "Enable fsnotify based file watcher instead of interval polling. (mutually exclusive with '--schedule')")
+	watchDebounce := flag.Duration("watch-debounce", 500*time.Millisecond, "Debounce window used to coalesce bursts of '--watch' filesystem events.")
+	watchSafetyInterval := flag.Duration("watch-safety-interval", 5*time.Minute, "Background safety-net interval to re-probe endpoint health in '--watch' mode even when no file event fired.")
+	logLevel := flag.String("log-level", "info", "Minimum log level to emit: 'debug', 'info', 'warn' or 'error'.")
+	logFormat := flag.String("log-format", "logfmt", "Log output format: 'logfmt' or 'json'.")
+	logSampling := flag.Duration("log-sampling", 0, "Suppress repeated endpoint probe error lines within this window, emitting a single line with the suppressed count once it rolls over. 0 disables sampling.")
 	version := flag.Bool("version", false, "Show version.")
 
 	flag.Parse()
@@ -255,19 +325,120 @@ func main() {
 		os.Exit(0)
 	}
 
-	if (*directory == "" && *file == "") || (*directory != "" && *file != "") {
-		log.Fatal("FATAL: Either '--directory' or '--file' argument should be set. (mutually exclusive)")
+	logger, err := newLogger(*logFormat, *logLevel)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	rootLogger = logger
+	errSampler = newLogSampler(*logSampling)
+
+	inputsSet := 0
+	for _, set := range []bool{*directory != "", *file != "", *hashringContent != "", *hashringURL != ""} {
+		if set {
+			inputsSet++
+		}
+	}
+	if inputsSet != 1 {
+		log.Fatal("FATAL: Exactly one of '--directory', '--file', '--hashring-content' or '--hashring-url' argument should be set.")
+	}
+
+	if (*hashringContent != "" || *hashringURL != "") && *output == "" {
+		log.Fatal("FATAL: '--output' is required when using '--hashring-content' or '--hashring-url'")
 	}
 
 	if *interval <= *endpointTimeout {
 		log.Fatalf("FATAL: '--interval %d'  must be greater than '--timeout %d'", *interval, *endpointTimeout)
 	}
 
-	if !*schedule {
-		hashringFiles := buildFilesList(*directory, *file, *verbose)
-		run(hashringFiles, *owner, *endpointScheme, *endpointTimeout, *endpointPortOffset, *verbose)
-	} else {
+	if *schedule && *watch {
+		log.Fatal("FATAL: '--schedule' and '--watch' are mutually exclusive.")
+	}
+
+	if *watch && *directory == "" && *file == "" {
+		log.Fatal("FATAL: '--watch' requires '--directory' or '--file' to be set.")
+	}
+
+	if len(sinkNames) > 0 && *directory != "" {
+		log.Fatal("FATAL: '--sink' is not supported with '--directory': every source would share (and clobber) the same sink's content. Use '--file', '--hashring-content' or '--hashring-url' instead.")
+	}
 
+	policy, err := parseFailurePolicy(*endpointFailurePolicy)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	retry := retryConfig{Retries: *endpointRetries, Backoff: *endpointRetryBackoff, MaxBackoff: *endpointRetryMaxBackoff}
+
+	var extraSinks []HashringSink
+	for _, name := range sinkNames {
+		switch name {
+		case "configmap":
+			if *configmapNamespace == "" || *configmapName == "" {
+				log.Fatal("FATAL: '--configmap-namespace' and '--configmap-name' are required for '--sink=configmap'")
+			}
+			sink, err := newConfigMapSink(*configmapNamespace, *configmapName, *configmapKey, *kubeconfig)
+			if err != nil {
+				log.Fatalf("FATAL: %v", err)
+			}
+			extraSinks = append(extraSinks, sink)
+		case "http":
+			if *sinkHTTPURL == "" {
+				log.Fatal("FATAL: '--sink-http-url' is required for '--sink=http'")
+			}
+			extraSinks = append(extraSinks, &HTTPSink{URL: *sinkHTTPURL, Token: *sinkHTTPToken, Retry: retry})
+		default:
+			log.Fatalf("FATAL: unknown '--sink' %q, expected 'configmap' or 'http'", name)
+		}
+	}
+
+	state := newControllerState()
+	// Sources are built once and reused across every reconciliation rather
+	// than re-created per tick: HTTPHashringSource in particular carries
+	// ETag/Last-Modified state between polls to make conditional requests,
+	// which would be lost if a fresh instance were built on every call.
+	// '--directory' is the exception: it is walked again on every run so
+	// hashring files created after startup are picked up.
+	sources := buildSources(*directory, *file, *hashringContent, *hashringURL, hashringURLHeaders, *endpointTimeout, rootLogger)
+	runOnce := func() {
+		runSources := sources
+		if *directory != "" {
+			runSources = buildSources(*directory, "", "", "", nil, *endpointTimeout, rootLogger)
+		}
+		run(runSources, *owner, *endpointScheme, *endpointTimeout, *endpointPortOffset, retry, policy, *output, extraSinks, state, rootLogger)
+	}
+
+	if *listenAddress != "" {
+		admin := newAdminServer(*listenAddress, state, runOnce)
+		go func() {
+			if err := admin.ListenAndServe(); err != nil {
+				level.Error(rootLogger).Log("msg", "admin HTTP server stopped", "err", err)
+			}
+		}()
+	}
+
+	switch {
+	case *watch:
+		runOnce()
+
+		go func() {
+			if err := watchSources(*directory, *file, *watchDebounce, runOnce, rootLogger); err != nil {
+				level.Error(rootLogger).Log("msg", "watcher stopped", "err", err)
+			}
+		}()
+
+		// Keep a slower background ticker as a safety net: receiver
+		// readiness can change even when the source file doesn't.
+		safetyTicker := time.NewTicker(*watchSafetyInterval)
+		go func() {
+			for t := range safetyTicker.C {
+				level.Info(rootLogger).Log("msg", "watch safety tick", "time", t)
+				runOnce()
+			}
+		}()
+
+		level.Info(rootLogger).Log("msg", "watcher started", "debounce", *watchDebounce, "safety_interval", *watchSafetyInterval)
+		waitForSignal()
+		level.Info(rootLogger).Log("msg", "watcher stopped")
+	case *schedule:
 		// Create and run the scheduler based on given interval
 		ticker := time.NewTicker(time.Duration(*interval) * time.Second)
 		scheduleDone := make(chan bool)
@@ -278,28 +449,25 @@ func main() {
 				case <-scheduleDone:
 					return
 				case t := <-ticker.C:
-					InfoLogger.Printf("Tick at %s", t)
-					hashringFiles := buildFilesList(*directory, *file, *verbose)
-					run(hashringFiles, *owner, *endpointScheme, *endpointTimeout, *endpointPortOffset, *verbose)
+					level.Info(rootLogger).Log("msg", "tick", "time", t)
+					runOnce()
 				}
 			}
 		}()
 
-		// Handle signals to stop the scheduler
-		sigs := make(chan os.Signal, 1)
-		signalDone := make(chan bool, 1)
-
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-		go func() {
-			sig := <-sigs
-			fmt.Println()
-			InfoLogger.Printf("Caught SIGTERM %v", sig)
-			signalDone <- true
-		}()
-
-		InfoLogger.Printf("Scheduler Started (run every %d seconds)", *interval)
-		<-signalDone
-		InfoLogger.Println("Scheduler Stopped...")
+		level.Info(rootLogger).Log("msg", "scheduler started", "interval", *interval)
+		waitForSignal()
+		level.Info(rootLogger).Log("msg", "scheduler stopped")
+	default:
+		runOnce()
 	}
 }
+
+// waitForSignal blocks until SIGINT or SIGTERM is received.
+func waitForSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	fmt.Println()
+	level.Info(rootLogger).Log("msg", "caught signal", "signal", sig)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	hashringEndpoints = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "thanos_receive_controller_hashring_endpoints",
+		Help: "Number of endpoints per hashring, by probe outcome.",
+	}, []string{"hashring", "state"})
+
+	probeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "thanos_receive_controller_probe_duration_seconds",
+		Help:    "Duration of an endpoint readiness probe, including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	probeErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_receive_controller_probe_errors_total",
+		Help: "Total number of failed endpoint readiness probe attempts.",
+	}, []string{"endpoint"})
+
+	configChangesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_receive_controller_configmap_changes_total",
+		Help: "Total number of times a generated hashring configuration was written to a sink.",
+	}, []string{"source"})
+
+	lastReconcileTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "thanos_receive_controller_last_reconcile_timestamp_seconds",
+		Help: "Unix timestamp of the last completed reconciliation run.",
+	})
+)
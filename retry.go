@@ -0,0 +1,41 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// retryConfig controls how many times a failed readiness probe is retried
+// and how long to wait between attempts.
+type retryConfig struct {
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// backoffDuration returns the exponential backoff with jitter to wait before
+// retry attempt "attempt" (0-indexed): min(max, base * 2^attempt) * (0.5 + rand*0.5).
+func backoffDuration(attempt int, base, max time.Duration) time.Duration {
+	backoff := base << attempt
+	if backoff <= 0 || backoff > max {
+		backoff = max
+	}
+	jitter := 0.5 + rand.Float64()*0.5
+	return time.Duration(float64(backoff) * jitter)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDuration(t *testing.T) {
+	cases := []struct {
+		name       string
+		attempt    int
+		base       time.Duration
+		max        time.Duration
+		wantAtLeast time.Duration
+		wantAtMost  time.Duration
+	}{
+		{
+			name:        "first attempt is between half and a full base",
+			attempt:     0,
+			base:        time.Second,
+			max:         time.Minute,
+			wantAtLeast: 500 * time.Millisecond,
+			wantAtMost:  time.Second,
+		},
+		{
+			name:        "doubles on each attempt",
+			attempt:     2,
+			base:        time.Second,
+			max:         time.Minute,
+			wantAtLeast: 2 * time.Second,
+			wantAtMost:  4 * time.Second,
+		},
+		{
+			name:        "clamped at max once the exponential exceeds it",
+			attempt:     10,
+			base:        time.Second,
+			max:         30 * time.Second,
+			wantAtLeast: 15 * time.Second,
+			wantAtMost:  30 * time.Second,
+		},
+		{
+			name:        "clamped at max when attempt is large enough to overflow",
+			attempt:     63,
+			base:        time.Second,
+			max:         30 * time.Second,
+			wantAtLeast: 15 * time.Second,
+			wantAtMost:  30 * time.Second,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				got := backoffDuration(tc.attempt, tc.base, tc.max)
+				if got < tc.wantAtLeast || got > tc.wantAtMost {
+					t.Fatalf("backoffDuration(%d, %s, %s) = %s, want between %s and %s", tc.attempt, tc.base, tc.max, got, tc.wantAtLeast, tc.wantAtMost)
+				}
+			}
+		})
+	}
+}
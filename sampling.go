@@ -0,0 +1,68 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// logSampler rate-limits repetitive error lines sharing the same key (e.g.
+// a persistently down endpoint) by suppressing duplicates within a window
+// and letting the caller emit a single summary line with the count once
+// the window rolls over.
+type logSampler struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*sampleEntry
+}
+
+type sampleEntry struct {
+	windowStart time.Time
+	suppressed  int
+}
+
+func newLogSampler(window time.Duration) *logSampler {
+	return &logSampler{window: window, entries: make(map[string]*sampleEntry)}
+}
+
+// allow reports whether the caller should emit a log line for key, and how
+// many occurrences were suppressed since the last one that was allowed. A
+// zero window disables sampling: every call is allowed.
+func (s *logSampler) allow(key string) (bool, int) {
+	if s.window <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || now.Sub(entry.windowStart) >= s.window {
+		suppressed := 0
+		if ok {
+			suppressed = entry.suppressed
+		}
+		s.entries[key] = &sampleEntry{windowStart: now}
+		return true, suppressed
+	}
+
+	entry.suppressed++
+	return false, 0
+}
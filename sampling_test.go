@@ -0,0 +1,82 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogSamplerDisabledWindow(t *testing.T) {
+	s := newLogSampler(0)
+	for i := 0; i < 3; i++ {
+		allow, suppressed := s.allow("endpoint-a")
+		if !allow || suppressed != 0 {
+			t.Fatalf("call %d: got allow=%v suppressed=%d, want allow=true suppressed=0", i, allow, suppressed)
+		}
+	}
+}
+
+func TestLogSamplerSuppressesWithinWindow(t *testing.T) {
+	s := newLogSampler(time.Hour)
+
+	allow, suppressed := s.allow("endpoint-a")
+	if !allow || suppressed != 0 {
+		t.Fatalf("first call: got allow=%v suppressed=%d, want allow=true suppressed=0", allow, suppressed)
+	}
+
+	for i := 0; i < 3; i++ {
+		allow, _ := s.allow("endpoint-a")
+		if allow {
+			t.Fatalf("call %d within window: expected allow=false", i)
+		}
+	}
+}
+
+func TestLogSamplerKeysAreIndependent(t *testing.T) {
+	s := newLogSampler(time.Hour)
+
+	if allow, _ := s.allow("endpoint-a"); !allow {
+		t.Fatal("expected first call for endpoint-a to be allowed")
+	}
+	if allow, _ := s.allow("endpoint-b"); !allow {
+		t.Fatal("expected first call for a different key endpoint-b to be allowed, independent of endpoint-a")
+	}
+}
+
+func TestLogSamplerReportsSuppressedCountOnceWindowRolls(t *testing.T) {
+	s := newLogSampler(10 * time.Millisecond)
+
+	if allow, _ := s.allow("endpoint-a"); !allow {
+		t.Fatal("expected first call to be allowed")
+	}
+	for i := 0; i < 2; i++ {
+		if allow, _ := s.allow("endpoint-a"); allow {
+			t.Fatalf("call %d: expected to be suppressed within the window", i)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	allow, suppressed := s.allow("endpoint-a")
+	if !allow {
+		t.Fatal("expected the call after the window rolled over to be allowed")
+	}
+	if suppressed != 2 {
+		t.Fatalf("got suppressed=%d, want 2", suppressed)
+	}
+}
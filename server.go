@@ -0,0 +1,93 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// adminServer exposes /metrics, the standard /-/healthy and /-/ready probe
+// endpoints, and an /api/v1 admin surface backed by the controller's state.
+type adminServer struct {
+	addr   string
+	state  *controllerState
+	reload func()
+}
+
+func newAdminServer(addr string, state *controllerState, reload func()) *adminServer {
+	return &adminServer{addr: addr, state: state, reload: reload}
+}
+
+func (s *adminServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/-/healthy", s.handleHealthy)
+	mux.HandleFunc("/-/ready", s.handleReady)
+	mux.HandleFunc("/api/v1/hashrings", s.handleHashrings)
+	mux.HandleFunc("/api/v1/reload", s.handleReload)
+	mux.HandleFunc("/api/v1/status", s.handleStatus)
+	return mux
+}
+
+// ListenAndServe starts the admin HTTP server. It blocks until the server
+// stops and is meant to be run in its own goroutine.
+func (s *adminServer) ListenAndServe() error {
+	return http.ListenAndServe(s.addr, s.handler())
+}
+
+func (s *adminServer) handleHealthy(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func (s *adminServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func (s *adminServer) handleHashrings(w http.ResponseWriter, r *http.Request) {
+	statuses, _ := s.state.snapshot()
+	writeJSON(w, statuses)
+}
+
+func (s *adminServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	go s.reload()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *adminServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, lastReconcile := s.state.snapshot()
+	writeJSON(w, struct {
+		LastReconcile interface{}    `json:"lastReconcile"`
+		Sources       []SourceStatus `json:"sources"`
+	}{LastReconcile: lastReconcile, Sources: statuses})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		level.Error(rootLogger).Log("msg", "error encoding admin API response", "err", err)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// HashringSink is a destination the generated hashring JSON can be written
+// to. Sinks are composable: the controller writes to every configured sink
+// on each reconciliation.
+type HashringSink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Current returns the content currently stored at the sink, and false
+	// if none exists yet (e.g. first run).
+	Current() ([]byte, bool)
+	// Write persists the new content to the sink.
+	Write(content []byte) error
+}
+
+// contentChanged reports whether two hashring JSON payloads differ, using
+// a sha256 comparison rather than a byte-by-byte one.
+func contentChanged(a, b []byte) bool {
+	return sha256.Sum256(a) != sha256.Sum256(b)
+}
+
+// writeIfChanged writes content to sink unless the sink already holds the
+// same content, in which case it's a no-op. It returns whether a write was
+// performed.
+func writeIfChanged(sink HashringSink, content []byte) (bool, error) {
+	if current, ok := sink.Current(); ok && !contentChanged(content, current) {
+		return false, nil
+	}
+	if err := sink.Write(content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sinkNamesFlag implements flag.Value to let --sink be repeated on the
+// command line, e.g. -sink configmap -sink http.
+type sinkNamesFlag []string
+
+func (f *sinkNamesFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *sinkNamesFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// FileSink writes the generated hashring JSON to a local file and chowns it
+// to the configured owner, mirroring the controller's original behavior.
+type FileSink struct {
+	Path  string
+	Owner string
+	// Logger is used to report successful writes. Falls back to rootLogger
+	// when nil.
+	Logger kitlog.Logger
+}
+
+func (s *FileSink) logger() kitlog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return rootLogger
+}
+
+func (s *FileSink) Name() string { return s.Path }
+
+func (s *FileSink) Current() ([]byte, bool) {
+	content, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+func (s *FileSink) Write(content []byte) error {
+	g, err := user.Lookup(s.Owner)
+	if err != nil {
+		return fmt.Errorf("looking up owner %s: %w", s.Owner, err)
+	}
+
+	if err := ioutil.WriteFile(s.Path, content, 0644); err != nil {
+		return fmt.Errorf("writing file %s: %w", s.Path, err)
+	}
+
+	uid, _ := strconv.Atoi(g.Uid)
+	gid, _ := strconv.Atoi(g.Gid)
+	if err := os.Chown(s.Path, uid, gid); err != nil {
+		return fmt.Errorf("setting owner %s on file %s: %w", s.Owner, s.Path, err)
+	}
+
+	level.Info(s.logger()).Log("msg", "file saved", "path", s.Path)
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ConfigMapSink writes the generated hashring JSON into a key of a
+// Kubernetes ConfigMap, so receivers can mount it without a hostPath. It
+// only issues a write when the stored data differs, keeping the ConfigMap's
+// resourceVersion/event history quiet.
+type ConfigMapSink struct {
+	Namespace string
+	CMName    string
+	Key       string
+
+	clientset kubernetes.Interface
+}
+
+// newConfigMapSink builds a ConfigMapSink, authenticating with in-cluster
+// credentials when available and falling back to the local kubeconfig
+// otherwise (e.g. for local testing).
+func newConfigMapSink(namespace, name, key, kubeconfig string) (*ConfigMapSink, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("building kubernetes client config: %w", err)
+		}
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("building kubernetes clientset: %w", err)
+	}
+
+	return &ConfigMapSink{Namespace: namespace, CMName: name, Key: key, clientset: clientset}, nil
+}
+
+func (s *ConfigMapSink) Name() string {
+	return fmt.Sprintf("configmap:%s/%s", s.Namespace, s.CMName)
+}
+
+func (s *ConfigMapSink) Current() ([]byte, bool) {
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.Namespace).Get(context.Background(), s.CMName, metav1.GetOptions{})
+	if err != nil {
+		return nil, false
+	}
+	data, ok := cm.Data[s.Key]
+	if !ok {
+		return nil, false
+	}
+	return []byte(data), true
+}
+
+func (s *ConfigMapSink) Write(content []byte) error {
+	ctx := context.Background()
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.Namespace).Get(ctx, s.CMName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.CMName, Namespace: s.Namespace},
+			Data:       map[string]string{s.Key: string(content)},
+		}
+		_, err = s.clientset.CoreV1().ConfigMaps(s.Namespace).Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("getting configmap %s/%s: %w", s.Namespace, s.CMName, err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[s.Key] = string(content)
+	_, err = s.clientset.CoreV1().ConfigMaps(s.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPSink PUTs the generated hashring JSON to a configurable URL, with
+// retry/backoff and optional bearer-token auth. Since the remote side
+// doesn't expose a way to read back what it currently holds, it keeps track
+// of the last content it successfully pushed to dedup consecutive writes.
+type HTTPSink struct {
+	URL   string
+	Token string
+	Retry retryConfig
+
+	mu         sync.Mutex
+	lastPushed []byte
+}
+
+func (s *HTTPSink) Name() string { return fmt.Sprintf("http:%s", s.URL) }
+
+func (s *HTTPSink) Current() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastPushed == nil {
+		return nil, false
+	}
+	return s.lastPushed, true
+}
+
+func (s *HTTPSink) Write(content []byte) error {
+	var lastErr error
+	for attempt := 0; attempt <= s.Retry.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoffDuration(attempt-1, s.Retry.Backoff, s.Retry.MaxBackoff))
+		}
+
+		req, err := http.NewRequest(http.MethodPut, s.URL, bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("building request for %s: %w", s.URL, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if s.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.Token)
+		}
+
+		resp, err := httpClient(10).Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			s.mu.Lock()
+			s.lastPushed = content
+			s.mu.Unlock()
+			return nil
+		}
+		lastErr = fmt.Errorf("unexpected status %d pushing to %s: %s", resp.StatusCode, s.URL, string(body))
+	}
+	return fmt.Errorf("pushing to %s after %d attempt(s): %w", s.URL, s.Retry.Retries+1, lastErr)
+}
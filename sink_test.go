@@ -0,0 +1,97 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+// fakeSink is a minimal in-memory HashringSink for exercising writeIfChanged
+// without touching the filesystem, Kubernetes or the network.
+type fakeSink struct {
+	name    string
+	content []byte
+	has     bool
+	writes  int
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Current() ([]byte, bool) {
+	return s.content, s.has
+}
+
+func (s *fakeSink) Write(content []byte) error {
+	s.content = content
+	s.has = true
+	s.writes++
+	return nil
+}
+
+func TestContentChanged(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []byte
+		want bool
+	}{
+		{name: "identical content", a: []byte(`[{"hashring":"a"}]`), b: []byte(`[{"hashring":"a"}]`), want: false},
+		{name: "different content", a: []byte(`[{"hashring":"a"}]`), b: []byte(`[{"hashring":"b"}]`), want: true},
+		{name: "both empty", a: []byte{}, b: []byte{}, want: false},
+		{name: "empty vs non-empty", a: []byte{}, b: []byte(`[]`), want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := contentChanged(tc.a, tc.b); got != tc.want {
+				t.Fatalf("contentChanged(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	t.Run("first write always happens when the sink is empty", func(t *testing.T) {
+		sink := &fakeSink{name: "test"}
+		changed, err := writeIfChanged(sink, []byte(`[]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !changed || sink.writes != 1 {
+			t.Fatalf("got changed=%v writes=%d, want changed=true writes=1", changed, sink.writes)
+		}
+	})
+
+	t.Run("no-op when content is unchanged", func(t *testing.T) {
+		sink := &fakeSink{name: "test", content: []byte(`[]`), has: true}
+		changed, err := writeIfChanged(sink, []byte(`[]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if changed || sink.writes != 0 {
+			t.Fatalf("got changed=%v writes=%d, want changed=false writes=0", changed, sink.writes)
+		}
+	})
+
+	t.Run("writes when content differs from what the sink holds", func(t *testing.T) {
+		sink := &fakeSink{name: "test", content: []byte(`[]`), has: true}
+		changed, err := writeIfChanged(sink, []byte(`[{"hashring":"a"}]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !changed || sink.writes != 1 {
+			t.Fatalf("got changed=%v writes=%d, want changed=true writes=1", changed, sink.writes)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// HashringSource abstracts where the raw hashring JSON configuration is read
+// from, so the controller can treat a local file, a directory, an inline
+// string and a remote HTTP endpoint the same way.
+type HashringSource interface {
+	// Name identifies the source in logs and in the admin API status.
+	Name() string
+	// Load returns the raw hashring JSON content for this source.
+	Load() ([]byte, error)
+}
+
+// FileHashringSource reads the hashring configuration from a single local file.
+type FileHashringSource struct {
+	Path string
+}
+
+func (s *FileHashringSource) Name() string { return s.Path }
+
+func (s *FileHashringSource) Load() ([]byte, error) {
+	return ioutil.ReadFile(s.Path)
+}
+
+// InlineHashringSource serves a static hashring configuration passed as raw
+// JSON, e.g. via the --hashring-content flag.
+type InlineHashringSource struct {
+	Content string
+}
+
+func (s *InlineHashringSource) Name() string { return "inline" }
+
+func (s *InlineHashringSource) Load() ([]byte, error) {
+	return []byte(s.Content), nil
+}
+
+// HTTPHashringSource fetches the hashring configuration from a remote
+// HTTP(S) endpoint, mirroring Thanos receive's --receive.hashrings
+// alternative. It remembers the last ETag/Last-Modified response headers so
+// repeated polls can use conditional requests; on a 304 Not Modified it
+// returns the content cached from the last full response instead of
+// downloading it again.
+type HTTPHashringSource struct {
+	URL     string
+	Headers map[string]string
+	Timeout int
+
+	lastETag     string
+	lastModified string
+	lastContent  []byte
+}
+
+func (s *HTTPHashringSource) Name() string { return s.URL }
+
+func (s *HTTPHashringSource) Load() ([]byte, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", s.URL, err)
+	}
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.lastETag != "" {
+		req.Header.Set("If-None-Match", s.lastETag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := httpClient(s.Timeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", s.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return s.lastContent, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, s.URL)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body from %s: %w", s.URL, err)
+	}
+
+	s.lastETag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.lastContent = body
+
+	return body, nil
+}
+
+// headerFlag implements flag.Value to let --hashring-url-header be repeated
+// on the command line, e.g. -hashring-url-header "Authorization: Bearer xxx".
+type headerFlag map[string]string
+
+func (h headerFlag) String() string {
+	var pairs []string
+	for key, value := range h {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, value))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid header %q, expected \"Key: Value\"", value)
+	}
+	h[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	return nil
+}
+
+// buildSources resolves the configured input flags into the list of
+// HashringSource to process. --file, --directory, --hashring-content and
+// --hashring-url are mutually exclusive.
+func buildSources(directory, file, hashringContent, hashringURL string, hashringURLHeaders map[string]string, timeout int, logger kitlog.Logger) []HashringSource {
+	var sources []HashringSource
+	switch {
+	case hashringContent != "":
+		sources = append(sources, &InlineHashringSource{Content: hashringContent})
+	case hashringURL != "":
+		sources = append(sources, &HTTPHashringSource{URL: hashringURL, Headers: hashringURLHeaders, Timeout: timeout})
+	case file != "":
+		sources = append(sources, &FileHashringSource{Path: file})
+	default:
+		for _, path := range listHashringFiles(directory, logger) {
+			sources = append(sources, &FileHashringSource{Path: path})
+		}
+	}
+
+	var names []string
+	for _, source := range sources {
+		names = append(names, source.Name())
+	}
+	level.Debug(logger).Log("msg", "watching sources", "sources", fmt.Sprintf("%v", names))
+	return sources
+}
+
+// generatedFilePath derives the path the generated hashring JSON should be
+// written to for a given source. File sources keep the existing
+// "<name>_generated.json" sibling convention; other sources fall back to
+// the --output flag.
+func generatedFilePath(source HashringSource, output string) string {
+	if fileSource, ok := source.(*FileHashringSource); ok {
+		return fmt.Sprintf("%s_generated.json", strings.TrimSuffix(fileSource.Path, ".json"))
+	}
+	return output
+}
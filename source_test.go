@@ -0,0 +1,128 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHashringSourceLoad(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer xxx" {
+			t.Errorf("request %d: missing/wrong Authorization header: got %q", requests, got)
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"hashring":"default","endpoints":["127.0.0.1:10901"]}]`))
+	}))
+	defer server.Close()
+
+	source := &HTTPHashringSource{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer xxx"}, Timeout: 5}
+
+	// First poll: no conditional headers sent yet, full body returned.
+	body, err := source.Load()
+	if err != nil {
+		t.Fatalf("first Load: unexpected error: %v", err)
+	}
+	want := `[{"hashring":"default","endpoints":["127.0.0.1:10901"]}]`
+	if string(body) != want {
+		t.Fatalf("first Load: got %q, want %q", body, want)
+	}
+
+	// Second poll on the SAME source instance: should send If-None-Match
+	// and get back a 304, reusing the cached content from the first poll.
+	body, err = source.Load()
+	if err != nil {
+		t.Fatalf("second Load: unexpected error: %v", err)
+	}
+	if string(body) != want {
+		t.Fatalf("second Load (304): got %q, want cached %q", body, want)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestHTTPHashringSourceLoadNewInstanceNeverSendsConditionalHeaders(t *testing.T) {
+	// A fresh HTTPHashringSource carries no ETag/Last-Modified state, so it
+	// sends neither If-None-Match nor If-Modified-Since, even after the
+	// "remote" has already been fetched once by a previous, unrelated
+	// instance. This is why sources are reused across polls.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-None-Match"); got != "" {
+			t.Errorf("expected no If-None-Match on a fresh source instance, got %q", got)
+		}
+		if got := r.Header.Get("If-Modified-Since"); got != "" {
+			t.Errorf("expected no If-Modified-Since on a fresh source instance, got %q", got)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		source := &HTTPHashringSource{URL: server.URL, Timeout: 5}
+		if _, err := source.Load(); err != nil {
+			t.Fatalf("tick %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestHTTPHashringSourceLoadErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source := &HTTPHashringSource{URL: server.URL, Timeout: 5}
+	if _, err := source.Load(); err == nil {
+		t.Fatal("expected an error for a non-200/304 response, got nil")
+	}
+}
+
+func TestGeneratedFilePath(t *testing.T) {
+	cases := []struct {
+		name   string
+		source HashringSource
+		output string
+		want   string
+	}{
+		{name: "file source derives a sibling _generated.json", source: &FileHashringSource{Path: "/etc/thanos/hashrings.json"}, output: "", want: "/etc/thanos/hashrings_generated.json"},
+		{name: "inline source falls back to --output", source: &InlineHashringSource{Content: "[]"}, output: "/tmp/out.json", want: "/tmp/out.json"},
+		{name: "http source falls back to --output", source: &HTTPHashringSource{URL: "http://example/hashrings"}, output: "/tmp/out.json", want: "/tmp/out.json"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := generatedFilePath(tc.source, tc.output); got != tc.want {
+				t.Fatalf("generatedFilePath() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
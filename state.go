@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// EndpointProbe records the outcome of a single endpoint readiness probe.
+type EndpointProbe struct {
+	Endpoint string `json:"endpoint"`
+	Healthy  bool   `json:"healthy"`
+}
+
+// SourceStatus is the last known state of a single hashring source, exposed
+// through the admin API.
+type SourceStatus struct {
+	Source        string                     `json:"source"`
+	GeneratedFile string                     `json:"generatedFile"`
+	Content       string                     `json:"content"`
+	Sha256        string                     `json:"sha256"`
+	LastRun       time.Time                  `json:"lastRun"`
+	Probes        map[string][]EndpointProbe `json:"probes"`
+}
+
+// controllerState holds the last known status of every configured source so
+// the admin HTTP API can report it without re-running a reconciliation.
+type controllerState struct {
+	mu            sync.RWMutex
+	sources       map[string]SourceStatus
+	lastReconcile time.Time
+}
+
+func newControllerState() *controllerState {
+	return &controllerState{sources: make(map[string]SourceStatus)}
+}
+
+func (s *controllerState) update(status SourceStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[status.Source] = status
+}
+
+func (s *controllerState) setLastReconcile(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastReconcile = t
+}
+
+func (s *controllerState) snapshot() ([]SourceStatus, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]SourceStatus, 0, len(s.sources))
+	for _, status := range s.sources {
+		statuses = append(statuses, status)
+	}
+	return statuses, s.lastReconcile
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2022 François Gouteroux <francois.gouteroux@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// addRecursive registers watcher on dir and every subdirectory beneath it,
+// mirroring the recursive walk listHashringFiles does for --schedule/one-shot
+// runs so --watch picks up hashring files regardless of how deep they live.
+func addRecursive(watcher *fsnotify.Watcher, dir string, logger kitlog.Logger) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return err
+		}
+		level.Debug(logger).Log("msg", "watching directory", "path", path)
+		return nil
+	})
+}
+
+// watchSources watches the directory (recursively, including subdirectories
+// created later) or the single file's parent directory for create/write/
+// rename/remove events using fsnotify. Bursts of events within `debounce` of
+// each other are coalesced into a single call to reconcile. Writes to
+// "_generated.json" files are ignored so the controller's own output
+// doesn't trigger a feedback loop.
+func watchSources(directory, file string, debounce time.Duration, reconcile func(), logger kitlog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if file != "" {
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+	} else {
+		if err := addRecursive(watcher, directory, logger); err != nil {
+			return err
+		}
+	}
+
+	var debounceTimer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addRecursive(watcher, event.Name, logger); err != nil {
+						level.Error(logger).Log("msg", "failed to watch new subdirectory", "path", event.Name, "err", err)
+					}
+				}
+			}
+			if strings.HasSuffix(event.Name, "_generated.json") || !strings.HasSuffix(event.Name, ".json") {
+				continue
+			}
+			level.Debug(logger).Log("msg", "watch event", "op", event.Op, "name", event.Name)
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			debounceTimer = time.AfterFunc(debounce, reconcile)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			level.Error(logger).Log("msg", "watch error", "err", err)
+		}
+	}
+}